@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -35,9 +37,25 @@ type Application struct {
 	logger          log.Logger
 	state           *State
 	snapshots       *SnapshotStore
+	mempool         *Mempool
 	cfg             *Config
 	restoreSnapshot *abci.Snapshot
 	restoreChunks   [][]byte
+	// restoreApplied tracks, by index, which chunks ApplySnapshotChunk has
+	// actually been called for during this run of the app. The statesync
+	// syncer decides which indices to fetch and submit purely from its own
+	// bookkeeping, oblivious to restoreChunks having been pre-filled by
+	// resuming chunks persisted by a previous, crashed run -- so the restore
+	// can't be considered done, and restoreSnapshot cleared, until every
+	// index has actually been submitted this run, even if state.Import ran
+	// earlier because the resumed data alone was already complete.
+	restoreApplied map[uint32]bool
+	// restoreImported records that state.Import has already run for the
+	// current restoreSnapshot, so that further ApplySnapshotChunk calls the
+	// syncer makes for indices restoreApplied hasn't seen yet -- because
+	// their data was resumed rather than received this run -- are simply
+	// acknowledged instead of re-verified and re-imported.
+	restoreImported bool
 }
 
 // Config allows for the setting of high level parameters for running the e2e Application
@@ -79,13 +97,43 @@ type Config struct {
 	//
 	// height <-> pubkey <-> voting power
 	ValidatorUpdates map[string]map[string]uint8 `toml:"validator_update"`
+
+	// VoteExtensionsEnableHeight mirrors the VoteExtensionsEnableHeight
+	// consensus parameter. ExtendVote and VerifyVoteExtension refuse to
+	// produce or accept extensions at heights below it, and always refuse at
+	// height 0. Defaults to 0, which disables the check.
+	VoteExtensionsEnableHeight int64 `toml:"vote_extensions_enable_height"`
+
+	// VoteExtensionsEnableHeightPanic selects how ExtendVote and
+	// VerifyVoteExtension both react to a call below
+	// VoteExtensionsEnableHeight. If true, both panic, crashing the node so
+	// the misconfiguration can't go unnoticed. If false (the default), both
+	// instead log the violation and fail gracefully -- ExtendVote returns an
+	// empty extension and VerifyVoteExtension returns REJECT -- so a node
+	// mid-upgrade degrades the same way its peers do instead of crashing.
+	VoteExtensionsEnableHeightPanic bool `toml:"vote_extensions_enable_height_panic"`
+
+	// VoteExtensionQuorumFraction is the fraction (e.g. 2.0/3.0) of
+	// LocalLastCommit's total voting power that must be represented by
+	// contributing vote extensions before an extensionSum aggregate is
+	// emitted. ProcessProposal re-verifies the same threshold from the
+	// block's commit info before accepting.
+	VoteExtensionQuorumFraction float64 `toml:"vote_extension_quorum_fraction"`
+
+	// MempoolLaneLowPrefixes lists tx key prefixes (the part of a "key=value"
+	// tx before the "=", as parsed by parseTx) that the application mempool
+	// deprioritizes into LaneLow instead of LaneDefault. Defaults to none, in
+	// which case LaneLow is never assigned and every non-vote-extension tx
+	// stays in LaneDefault.
+	MempoolLaneLowPrefixes []string `toml:"mempool_lane_low_prefixes"`
 }
 
 func DefaultConfig(dir string) *Config {
 	return &Config{
-		PersistInterval:  1,
-		SnapshotInterval: 100,
-		Dir:              dir,
+		PersistInterval:             1,
+		SnapshotInterval:            100,
+		Dir:                         dir,
+		VoteExtensionQuorumFraction: 2.0 / 3.0,
 	}
 }
 
@@ -104,10 +152,15 @@ func NewApplication(cfg *Config) (*Application, error) {
 		return nil, err
 	}
 
+	if cfg.VoteExtensionQuorumFraction == 0 {
+		cfg.VoteExtensionQuorumFraction = 2.0 / 3.0
+	}
+
 	return &Application{
 		logger:    logger,
 		state:     state,
 		snapshots: snapshots,
+		mempool:   NewMempool(laneClassifier(cfg)),
 		cfg:       cfg,
 	}, nil
 }
@@ -164,6 +217,7 @@ func (app *Application) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 			Log:  err.Error(),
 		}
 	}
+	app.mempool.Insert(req.Tx)
 	return abci.ResponseCheckTx{Code: code.CodeTypeOK, GasWanted: 1}
 }
 
@@ -179,7 +233,23 @@ func (app *Application) FinalizeBlock(req abci.RequestFinalizeBlock) abci.Respon
 		if err != nil {
 			panic(err) // shouldn't happen since we verified it in CheckTx
 		}
-		app.state.Set(key, value)
+		if key == voteExtensionKey {
+			// value is "<sum>|<addr1,addr2,...>" (see formatExtTx); unpack
+			// it into a plain sum in extensionSum plus the signers in a
+			// separate key, instead of storing the raw "<sum>|<addrs>"
+			// string.
+			sum, signers, err := parseExtTx(value)
+			if err != nil {
+				panic(err) // shouldn't happen since ProcessProposal verified it
+			}
+			app.state.Set(voteExtensionKey, strconv.FormatInt(sum, 10))
+			if len(signers) > 0 {
+				app.state.Set(voteExtensionKey+".signers", strings.Join(signers, ","))
+			}
+		} else {
+			app.state.Set(key, value)
+		}
+		app.mempool.Remove(tx)
 
 		txs[i] = &abci.ExecTxResult{Code: code.CodeTypeOK}
 	}
@@ -264,16 +334,26 @@ func (app *Application) ListSnapshots(req abci.RequestListSnapshots) abci.Respon
 	return abci.ResponseListSnapshots{Snapshots: snapshots}
 }
 
-// LoadSnapshotChunk implements ABCI.
+// LoadSnapshotChunk implements ABCI. It returns the chunk bytes together
+// with a Merkle proof of the chunk's inclusion in the root published in the
+// snapshot's ListSnapshots.Metadata, so the receiver can verify the chunk
+// as soon as it arrives instead of waiting for the whole snapshot. Since
+// ResponseLoadSnapshotChunk has no dedicated proof field, the chunk and its
+// proof are bundled into a single envelope carried in Chunk; see
+// marshalChunkEnvelope.
 func (app *Application) LoadSnapshotChunk(req abci.RequestLoadSnapshotChunk) abci.ResponseLoadSnapshotChunk {
 	app.mu.Lock()
 	defer app.mu.Unlock()
 
-	chunk, err := app.snapshots.LoadChunk(req.Height, req.Format, req.Chunk)
+	chunk, proof, err := app.snapshots.LoadChunkWithProof(req.Height, req.Format, req.Chunk)
+	if err != nil {
+		panic(err)
+	}
+	envelope, err := marshalChunkEnvelope(chunk, proof)
 	if err != nil {
 		panic(err)
 	}
-	return abci.ResponseLoadSnapshotChunk{Chunk: chunk}
+	return abci.ResponseLoadSnapshotChunk{Chunk: envelope}
 }
 
 // OfferSnapshot implements ABCI.
@@ -285,11 +365,41 @@ func (app *Application) OfferSnapshot(req abci.RequestOfferSnapshot) abci.Respon
 		panic("A snapshot is already being restored")
 	}
 	app.restoreSnapshot = req.Snapshot
-	app.restoreChunks = [][]byte{}
+	app.restoreChunks = make([][]byte, req.Snapshot.Chunks)
+	app.restoreApplied = make(map[uint32]bool)
+	app.restoreImported = false
+
+	// Resume a restore left over from a previous run of the app: any chunk
+	// that was already verified and persisted to disk is loaded back into
+	// restoreChunks, so state.Import can run as soon as the chunks still
+	// missing have arrived. The syncer still submits every index (see
+	// restoreApplied). restoreDir is scoped by this
+	// snapshot's height/format/chunk-root identity, so leftover chunks from
+	// an abandoned restore of a *different* snapshot live under a different
+	// directory and are never picked up here.
+	entries, err := os.ReadDir(app.restoreDir())
+	if err == nil {
+		for _, entry := range entries {
+			var index uint32
+			if _, err := fmt.Sscanf(entry.Name(), "%d.chunk", &index); err != nil || index >= uint32(len(app.restoreChunks)) {
+				continue
+			}
+			bz, err := os.ReadFile(filepath.Join(app.restoreDir(), entry.Name()))
+			if err != nil {
+				continue
+			}
+			app.restoreChunks[index] = bz
+		}
+	}
 	return abci.ResponseOfferSnapshot{Result: abci.ResponseOfferSnapshot_ACCEPT}
 }
 
-// ApplySnapshotChunk implements ABCI.
+// ApplySnapshotChunk implements ABCI. Each chunk is verified against the
+// snapshot's published chunk root before being accepted, so a malformed or
+// malicious chunk is rejected immediately rather than only being noticed
+// once the whole snapshot has been downloaded and state.Import is called.
+// Chunk carries a marshalChunkEnvelope payload rather than the raw chunk
+// bytes, since RequestApplySnapshotChunk has no dedicated proof field.
 func (app *Application) ApplySnapshotChunk(req abci.RequestApplySnapshotChunk) abci.ResponseApplySnapshotChunk {
 	app.mu.Lock()
 	defer app.mu.Unlock()
@@ -297,82 +407,163 @@ func (app *Application) ApplySnapshotChunk(req abci.RequestApplySnapshotChunk) a
 	if app.restoreSnapshot == nil {
 		panic("No restore in progress")
 	}
-	app.restoreChunks = append(app.restoreChunks, req.Chunk)
-	if len(app.restoreChunks) == int(app.restoreSnapshot.Chunks) {
-		bz := []byte{}
-		for _, chunk := range app.restoreChunks {
-			bz = append(bz, chunk...)
+
+	app.restoreApplied[req.Index] = true
+
+	if !app.restoreImported {
+		root, err := snapshotRoot(app.restoreSnapshot.Metadata)
+		if err != nil {
+			app.logger.Error("snapshot has no verifiable chunk root", "err", err)
+			app.clearRestoreState()
+			return abci.ResponseApplySnapshotChunk{Result: abci.ResponseApplySnapshotChunk_REJECT_SNAPSHOT}
 		}
-		err := app.state.Import(app.restoreSnapshot.Height, bz)
+		chunk, proof, err := unmarshalChunkEnvelope(req.Chunk)
 		if err != nil {
-			panic(err)
+			app.logger.Error("failed to parse chunk envelope", "index", req.Index, "err", err)
+			return abci.ResponseApplySnapshotChunk{Result: abci.ResponseApplySnapshotChunk_RETRY}
+		}
+		if err := proof.Verify(root, chunk); err != nil {
+			app.logger.Error("chunk failed Merkle verification", "index", req.Index, "err", err)
+			app.clearRestoreState()
+			return abci.ResponseApplySnapshotChunk{Result: abci.ResponseApplySnapshotChunk_RETRY_SNAPSHOT}
 		}
-		app.restoreSnapshot = nil
-		app.restoreChunks = nil
-	}
-	return abci.ResponseApplySnapshotChunk{Result: abci.ResponseApplySnapshotChunk_ACCEPT}
-}
 
-func (app *Application) PrepareProposal(req abci.RequestPrepareProposal) abci.ResponsePrepareProposal {
-	var sum int64
-	var extCount int
-	for _, vote := range req.LocalLastCommit.Votes {
-		if !vote.SignedLastBlock || len(vote.VoteExtension) == 0 {
-			continue
+		if err := os.MkdirAll(app.restoreDir(), 0o755); err != nil {
+			panic(err)
 		}
-		extValue, err := parseVoteExtension(vote.VoteExtension)
-		// This should have been verified in VerifyVoteExtension
-		if err != nil {
-			panic(fmt.Errorf("failed to parse vote extension in PrepareProposal: %w", err))
+		if err := os.WriteFile(app.chunkPath(req.Index), chunk, 0o644); err != nil {
+			panic(err)
 		}
-		valAddr := crypto.Address(vote.Validator.Address)
-		app.logger.Info("got vote extension value in PrepareProposal", "valAddr", valAddr, "value", extValue)
-		sum += extValue
-		extCount++
-	}
-	// We only generate our special transaction if we have vote extensions
-	if extCount > 0 {
-		extTxPrefix := fmt.Sprintf("%s=", voteExtensionKey)
-		extTx := []byte(fmt.Sprintf("%s%d", extTxPrefix, sum))
-		app.logger.Info("preparing proposal with custom transaction from vote extensions", "tx", extTx)
-		// Our generated transaction takes precedence over any supplied
-		// transaction that attempts to modify the "extensionSum" value.
-		txRecords := make([]*abci.TxRecord, len(req.Txs)+1)
-		for i, tx := range req.Txs {
-			if strings.HasPrefix(string(tx), extTxPrefix) {
-				txRecords[i] = &abci.TxRecord{
-					Action: abci.TxRecord_REMOVED,
-					Tx:     tx,
-				}
-			} else {
-				txRecords[i] = &abci.TxRecord{
-					Action: abci.TxRecord_UNMODIFIED,
-					Tx:     tx,
-				}
+		app.restoreChunks[req.Index] = chunk
+
+		complete := true
+		for _, c := range app.restoreChunks {
+			if c == nil {
+				complete = false
+				break
 			}
 		}
-		txRecords[len(req.Txs)] = &abci.TxRecord{
-			Action: abci.TxRecord_ADDED,
-			Tx:     extTx,
-		}
-		return abci.ResponsePrepareProposal{
-			TxRecords: txRecords,
+		if complete {
+			bz := []byte{}
+			for _, c := range app.restoreChunks {
+				bz = append(bz, c...)
+			}
+			if err := app.state.Import(app.restoreSnapshot.Height, bz); err != nil {
+				panic(err)
+			}
+			app.restoreImported = true
 		}
 	}
-	// None of the transactions are modified by this application.
-	trs := make([]*abci.TxRecord, 0, len(req.Txs))
-	var totalBytes int64
-	for _, tx := range req.Txs {
-		totalBytes += int64(len(tx))
-		if totalBytes > req.MaxTxBytes {
-			break
+
+	// The restore is only truly done -- safe to clear restoreSnapshot so a
+	// later OfferSnapshot doesn't panic -- once every index has actually
+	// been submitted to ApplySnapshotChunk this run. restoreChunks being
+	// fully populated is not enough on its own: it can also be true because
+	// OfferSnapshot resumed chunks a previous, crashed run already
+	// persisted, and the syncer -- unaware of that -- still submits every
+	// index over the network regardless.
+	if app.restoreImported && len(app.restoreApplied) >= len(app.restoreChunks) {
+		app.clearRestoreState()
+	}
+	return abci.ResponseApplySnapshotChunk{Result: abci.ResponseApplySnapshotChunk_ACCEPT}
+}
+
+// clearRestoreState resets restoreSnapshot/restoreChunks and removes the
+// on-disk restore directory. It is called both on a completed restore and
+// on a chunk that fails verification: per the ABCI statesync protocol,
+// REJECT_SNAPSHOT and RETRY_SNAPSHOT both cause the syncer to call
+// OfferSnapshot again (with the same or a different candidate), and that
+// call panics if a restore is still marked in progress.
+func (app *Application) clearRestoreState() {
+	// Capture the restore directory before clearing restoreSnapshot: it is
+	// derived from the snapshot's identity, which is gone once the fields
+	// below are reset.
+	restoreDir := app.restoreDir()
+	app.restoreSnapshot = nil
+	app.restoreChunks = nil
+	app.restoreApplied = nil
+	app.restoreImported = false
+	if err := os.RemoveAll(restoreDir); err != nil {
+		app.logger.Error("failed to clean up restore directory", "err", err)
+	}
+}
+
+// restoreDir is where in-progress state-sync restore chunks for the current
+// restoreSnapshot are persisted, so that a restore can resume across app
+// restarts instead of starting over from the first chunk. It is scoped by
+// the snapshot's height, format, and published chunk root, so that chunks
+// left over from an abandoned restore of a different snapshot -- a
+// different height, or the same height re-exported with different chunk
+// contents -- land in a different directory and can never be picked up by
+// OfferSnapshot and trusted without being re-verified first.
+func (app *Application) restoreDir() string {
+	var rootHex string
+	if root, err := snapshotRoot(app.restoreSnapshot.Metadata); err == nil {
+		rootHex = hex.EncodeToString(root)
+	}
+	id := fmt.Sprintf("%d-%d-%s", app.restoreSnapshot.Height, app.restoreSnapshot.Format, rootHex)
+	return filepath.Join(app.cfg.Dir, "restore", id)
+}
+
+// chunkPath returns the on-disk path for a single verified restore chunk.
+func (app *Application) chunkPath(index uint32) string {
+	return filepath.Join(app.restoreDir(), fmt.Sprintf("%d.chunk", index))
+}
+
+func (app *Application) PrepareProposal(req abci.RequestPrepareProposal) abci.ResponsePrepareProposal {
+	sum, signers := aggregateVoteExtensions(app.logger, req.LocalLastCommit, app.cfg.VoteExtensionQuorumFraction)
+	extCount := len(signers)
+
+	// Drain the application's own lane-ordered mempool instead of naively
+	// using req.Txs in arrival order, so that high-priority txs (e.g.
+	// vote-extension-derived ones) are proposed ahead of ordinary user txs.
+	selected := app.mempool.Select(req.MaxTxBytes, -1)
+
+	extTxPrefix := fmt.Sprintf("%s=", voteExtensionKey)
+
+	if extCount == 0 {
+		// Without quorum, this is a routine round, not an exceptional one:
+		// every selected tx -- including a user-submitted "extensionSum="
+		// one -- passes through unmodified.
+		trs := make([]*abci.TxRecord, 0, len(selected))
+		for _, tx := range selected {
+			trs = append(trs, &abci.TxRecord{
+				Action: abci.TxRecord_UNMODIFIED,
+				Tx:     tx,
+			})
+		}
+		return abci.ResponsePrepareProposal{TxRecords: trs}
+	}
+
+	// We have vote extensions: generate our special transaction and have it
+	// take precedence over any selected tx that attempts to modify the
+	// "extensionSum" value.
+	extTx := formatExtTx(sum, signers)
+	app.logger.Info("preparing proposal with custom transaction from vote extensions", "tx", extTx, "signers", signers)
+	txRecords := make([]*abci.TxRecord, 0, len(selected)+1)
+	txRecords = append(txRecords, &abci.TxRecord{
+		Action: abci.TxRecord_ADDED,
+		Tx:     extTx,
+	})
+	for _, tx := range selected {
+		if strings.HasPrefix(string(tx), extTxPrefix) {
+			txRecords = append(txRecords, &abci.TxRecord{
+				Action: abci.TxRecord_REMOVED,
+				Tx:     tx,
+			})
+			// A tx PrepareProposal marks REMOVED is never committed, so it
+			// must also be evicted here -- otherwise it stays in the
+			// mempool forever and gets reselected by Select on every
+			// subsequent call, wasting MaxTxBytes budget.
+			app.mempool.Remove(tx)
+			continue
 		}
-		trs = append(trs, &abci.TxRecord{
+		txRecords = append(txRecords, &abci.TxRecord{
 			Action: abci.TxRecord_UNMODIFIED,
 			Tx:     tx,
 		})
 	}
-	return abci.ResponsePrepareProposal{TxRecords: trs}
+	return abci.ResponsePrepareProposal{TxRecords: txRecords}
 }
 
 // ProcessProposal implements part of the Application interface.
@@ -384,18 +575,46 @@ func (app *Application) ProcessProposal(req abci.RequestProcessProposal) abci.Re
 			app.logger.Error("malformed transaction in ProcessProposal", "tx", tx, "err", err)
 			return abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_REJECT}
 		}
-		// Additional check for vote extension-related txs
+		// Additional check for vote extension-related txs: re-verify that the
+		// contributing validators still meet quorum using the block's own
+		// commit info, since a malicious proposer could otherwise fabricate
+		// an extensionSum backed by insufficient voting power.
 		if k == voteExtensionKey {
-			_, err := strconv.Atoi(v)
+			_, signers, err := parseExtTx(v)
 			if err != nil {
 				app.logger.Error("malformed vote extension transaction", k, v, "err", err)
 				return abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_REJECT}
 			}
+			if !quorumMet(req.ProposedLastCommit, signers, app.cfg.VoteExtensionQuorumFraction) {
+				app.logger.Error("vote extension aggregate does not meet quorum", "signers", signers)
+				return abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_REJECT}
+			}
 		}
 	}
 	return abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_ACCEPT}
 }
 
+// rejectBelowEnableHeight reports whether height is below
+// cfg.VoteExtensionsEnableHeight (or zero), in which case ExtendVote and
+// VerifyVoteExtension must not produce or accept a vote extension. The
+// caller name is only used for logging/panic context. Depending on
+// cfg.VoteExtensionsEnableHeightPanic, the violation either panics or is
+// logged and left for the caller to fail gracefully, keeping both RPCs
+// consistent with each other.
+func (app *Application) rejectBelowEnableHeight(height int64, caller string) bool {
+	if height != 0 && height >= app.cfg.VoteExtensionsEnableHeight {
+		return false
+	}
+	err := fmt.Errorf("received call to %s at height %d, below VoteExtensionsEnableHeight %d",
+		caller, height, app.cfg.VoteExtensionsEnableHeight)
+	if app.cfg.VoteExtensionsEnableHeightPanic {
+		panic(err)
+	}
+	app.logger.Error("rejecting call below VoteExtensionsEnableHeight", "caller", caller,
+		"height", height, "enableHeight", app.cfg.VoteExtensionsEnableHeight)
+	return true
+}
+
 // ExtendVote will produce vote extensions in the form of random numbers to
 // demonstrate vote extension nondeterminism.
 //
@@ -404,6 +623,9 @@ func (app *Application) ProcessProposal(req abci.RequestProcessProposal) abci.Re
 // key/value store ("extensionSum") with the sum of all of the numbers collected
 // from the vote extensions.
 func (app *Application) ExtendVote(req abci.RequestExtendVote) abci.ResponseExtendVote {
+	if app.rejectBelowEnableHeight(req.Height, "ExtendVote") {
+		return abci.ResponseExtendVote{}
+	}
 	// We ignore any requests for vote extensions that don't match our expected
 	// next height.
 	if req.Height != int64(app.state.Height)+1 {
@@ -425,6 +647,11 @@ func (app *Application) ExtendVote(req abci.RequestExtendVote) abci.ResponseExte
 // without doing anything about them. In this case, it just makes sure that the
 // vote extension is a well-formed integer value.
 func (app *Application) VerifyVoteExtension(req abci.RequestVerifyVoteExtension) abci.ResponseVerifyVoteExtension {
+	if app.rejectBelowEnableHeight(req.Height, "VerifyVoteExtension") {
+		return abci.ResponseVerifyVoteExtension{
+			Status: abci.ResponseVerifyVoteExtension_REJECT,
+		}
+	}
 	// TODO: Should we reject vote extensions that don't match the next height?
 	// We allow vote extensions to be optional
 	if len(req.VoteExtension) == 0 {
@@ -491,6 +718,103 @@ func parseTx(tx []byte) (string, string, error) {
 	return string(parts[0]), string(parts[1]), nil
 }
 
+// aggregateVoteExtensions deterministically aggregates the vote extensions
+// of validators that signed the last block: contributions are deduplicated
+// per validator address and summed in address-sorted order, so that every
+// validator computes the identical result regardless of commit ordering.
+// The aggregate is only returned (non-nil signers) if the combined signed
+// voting power of the contributing validators exceeds quorumFraction of
+// commit's total voting power; otherwise it returns (0, nil).
+func aggregateVoteExtensions(
+	logger log.Logger, commit abci.ExtendedCommitInfo, quorumFraction float64,
+) (sum int64, signers []string) {
+	type contribution struct {
+		value int64
+		power int64
+	}
+	contributions := make(map[string]contribution)
+	var totalPower int64
+	for _, vote := range commit.Votes {
+		totalPower += vote.Validator.Power
+		if !vote.SignedLastBlock || len(vote.VoteExtension) == 0 {
+			continue
+		}
+		extValue, err := parseVoteExtension(vote.VoteExtension)
+		// This should have been verified in VerifyVoteExtension
+		if err != nil {
+			panic(fmt.Errorf("failed to parse vote extension: %w", err))
+		}
+		addr := crypto.Address(vote.Validator.Address).String()
+		// Guard against duplicate votes from the same validator so the
+		// aggregate stays deterministic even if the commit contains one.
+		if _, ok := contributions[addr]; ok {
+			continue
+		}
+		contributions[addr] = contribution{value: extValue, power: vote.Validator.Power}
+	}
+
+	addrs := make([]string, 0, len(contributions))
+	for addr := range contributions {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var signedPower int64
+	for _, addr := range addrs {
+		c := contributions[addr]
+		sum += c.value
+		signedPower += c.power
+		logger.Info("including vote extension in aggregate", "valAddr", addr, "value", c.value)
+	}
+
+	if totalPower == 0 || float64(signedPower) <= quorumFraction*float64(totalPower) {
+		return 0, nil
+	}
+	return sum, addrs
+}
+
+// quorumMet re-derives, from a block's (non-extended) commit info, whether
+// the validators listed in signers represent more than quorumFraction of
+// the total voting power. ProcessProposal uses this to re-verify the
+// threshold PrepareProposal applied, without needing the vote extension
+// values themselves.
+func quorumMet(commit abci.CommitInfo, signers []string, quorumFraction float64) bool {
+	signerSet := make(map[string]struct{}, len(signers))
+	for _, addr := range signers {
+		signerSet[addr] = struct{}{}
+	}
+	var totalPower, signedPower int64
+	for _, vote := range commit.Votes {
+		totalPower += vote.Validator.Power
+		addr := crypto.Address(vote.Validator.Address).String()
+		if _, ok := signerSet[addr]; ok && vote.SignedLastBlock {
+			signedPower += vote.Validator.Power
+		}
+	}
+	return totalPower > 0 && float64(signedPower) > quorumFraction*float64(totalPower)
+}
+
+// formatExtTx encodes the extensionSum aggregate and its contributing
+// validator addresses into the tx format understood by parseExtTx.
+func formatExtTx(sum int64, signers []string) []byte {
+	return []byte(fmt.Sprintf("%s=%d|%s", voteExtensionKey, sum, strings.Join(signers, ",")))
+}
+
+// parseExtTx parses the value half of an "extensionSum=<sum>|<addrs>" tx
+// into the aggregate sum and the contributing validator addresses.
+func parseExtTx(v string) (int64, []string, error) {
+	parts := strings.SplitN(v, "|", 2)
+	sum, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid extensionSum value %q: %w", parts[0], err)
+	}
+	var signers []string
+	if len(parts) == 2 && parts[1] != "" {
+		signers = strings.Split(parts[1], ",")
+	}
+	return sum, signers, nil
+}
+
 // parseVoteExtension attempts to parse the given extension data into a positive
 // integer value.
 func parseVoteExtension(ext []byte) (int64, error) {
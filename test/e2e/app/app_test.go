@@ -0,0 +1,57 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// TestFinalizeBlock_ExtensionSumTxStoresPlainSum verifies that committing a
+// formatExtTx-encoded "extensionSum=<sum>|<addrs>" tx through FinalizeBlock
+// stores a plain sum under extensionSum and the signers under a separate
+// extensionSum.signers key, instead of leaving the raw "<sum>|<addrs>" tx
+// value in extensionSum.
+func TestFinalizeBlock_ExtensionSumTxStoresPlainSum(t *testing.T) {
+	state, err := NewState(t.TempDir(), 1)
+	require.NoError(t, err)
+	app := &Application{
+		logger:  log.NewNopLogger(),
+		state:   state,
+		mempool: NewMempool(nil),
+		cfg:     &Config{},
+	}
+
+	app.FinalizeBlock(abci.RequestFinalizeBlock{
+		Height: 1,
+		Txs:    [][]byte{formatExtTx(123, []string{"addr1", "addr2"})},
+	})
+
+	require.Equal(t, "123", app.state.Get(voteExtensionKey))
+	require.Equal(t, "addr1,addr2", app.state.Get(voteExtensionKey+".signers"))
+}
+
+// TestPrepareProposal_WithoutQuorumPassesTxsThroughUnmodified verifies that
+// a round without quorum (extCount == 0) -- which is routine, not
+// exceptional -- leaves a user-submitted "extensionSum=" tx untouched
+// instead of stripping it.
+func TestPrepareProposal_WithoutQuorumPassesTxsThroughUnmodified(t *testing.T) {
+	app := &Application{
+		logger:  log.NewNopLogger(),
+		mempool: NewMempool(nil),
+		cfg: &Config{
+			VoteExtensionQuorumFraction: 2.0 / 3.0,
+		},
+	}
+
+	userTx := []byte("extensionSum=999")
+	app.mempool.Insert(userTx)
+
+	resp := app.PrepareProposal(abci.RequestPrepareProposal{MaxTxBytes: 1 << 20})
+
+	require.Len(t, resp.TxRecords, 1)
+	require.Equal(t, abci.TxRecord_UNMODIFIED, resp.TxRecords[0].Action)
+	require.Equal(t, string(userTx), string(resp.TxRecords[0].Tx))
+}
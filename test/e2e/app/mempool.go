@@ -0,0 +1,153 @@
+package app
+
+import (
+	"strings"
+	"sync"
+)
+
+// Lane identifies one of the application mempool's priority lanes. Lanes are
+// drained in ascending order (LaneHigh before LaneDefault before LaneLow) by
+// Mempool.Select.
+type Lane int
+
+const (
+	// LaneHigh carries vote-extension-derived transactions (e.g.
+	// "extensionSum="), which must land in the proposal ahead of user txs.
+	LaneHigh Lane = iota
+	// LaneDefault carries ordinary user transactions.
+	LaneDefault
+	// LaneLow carries transactions the application wants to deprioritize.
+	LaneLow
+)
+
+// lanes lists all lanes in drain order.
+var lanes = []Lane{LaneHigh, LaneDefault, LaneLow}
+
+// Classifier assigns a tx to a lane. Mempool calls it once per tx on
+// Insert, so the application can customize prioritization without changing
+// Mempool itself.
+type Classifier func(tx []byte) Lane
+
+// defaultClassifier sends vote-extension-derived txs to LaneHigh and
+// everything else to LaneDefault; it never assigns LaneLow. Use
+// laneClassifier to also deprioritize configured key prefixes into LaneLow.
+func defaultClassifier(tx []byte) Lane {
+	if strings.HasPrefix(string(tx), voteExtensionKey+"=") {
+		return LaneHigh
+	}
+	return LaneDefault
+}
+
+// laneClassifier builds a Classifier from cfg.MempoolLaneLowPrefixes: txs
+// whose parsed key has one of the configured prefixes are deprioritized
+// into LaneLow, so operators can exercise LaneLow without code changes.
+// Vote-extension-derived txs always take LaneHigh regardless of config.
+func laneClassifier(cfg *Config) Classifier {
+	lowPrefixes := cfg.MempoolLaneLowPrefixes
+	return func(tx []byte) Lane {
+		if strings.HasPrefix(string(tx), voteExtensionKey+"=") {
+			return LaneHigh
+		}
+		key, _, err := parseTx(tx)
+		if err == nil {
+			for _, prefix := range lowPrefixes {
+				if strings.HasPrefix(key, prefix) {
+					return LaneLow
+				}
+			}
+		}
+		return LaneDefault
+	}
+}
+
+// Mempool is a minimal application-side mempool with ordered lane support.
+// It is used by PrepareProposal in place of naively iterating the txs
+// supplied by the consensus reactor, so that the application controls
+// ordering and eviction instead of simply preserving arrival order.
+type Mempool struct {
+	mu       sync.Mutex
+	txs      map[Lane][][]byte
+	known    map[string]struct{}
+	classify Classifier
+}
+
+// NewMempool creates an empty Mempool that classifies txs with classify. A
+// nil classify falls back to defaultClassifier.
+func NewMempool(classify Classifier) *Mempool {
+	if classify == nil {
+		classify = defaultClassifier
+	}
+	return &Mempool{
+		txs:      make(map[Lane][][]byte),
+		known:    make(map[string]struct{}),
+		classify: classify,
+	}
+}
+
+// Insert classifies tx and adds it to the corresponding lane, unless it is
+// already present.
+func (m *Mempool) Insert(tx []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := string(tx)
+	if _, ok := m.known[key]; ok {
+		return
+	}
+	m.known[key] = struct{}{}
+	lane := m.classify(tx)
+	m.txs[lane] = append(m.txs[lane], tx)
+}
+
+// Remove deletes tx from whichever lane it occupies. Callers use this both
+// for txs that were committed in a block and for txs that PrepareProposal
+// marked REMOVED: in either case the tx must not be reselected by a future
+// PrepareProposal call, or it would waste MaxTxBytes budget forever.
+func (m *Mempool) Remove(tx []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := string(tx)
+	if _, ok := m.known[key]; !ok {
+		return
+	}
+	delete(m.known, key)
+	for _, lane := range lanes {
+		txs := m.txs[lane]
+		for i, t := range txs {
+			if string(t) == key {
+				m.txs[lane] = append(txs[:i], txs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// txGas is the flat gas cost CheckTx reports (GasWanted) for every tx. The
+// e2e app doesn't meter gas beyond that, so Select treats it as a constant.
+const txGas int64 = 1
+
+// Select drains lanes in priority order, returning as many txs as fit within
+// maxBytes and maxGas. It does not remove the selected txs from the
+// mempool; callers remove them once they are actually committed or
+// otherwise decided against (e.g. stripped by PrepareProposal).
+func (m *Mempool) Select(maxBytes, maxGas int64) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		selected         [][]byte
+		totalBytes, used int64
+	)
+	for _, lane := range lanes {
+		for _, tx := range m.txs[lane] {
+			totalBytes += int64(len(tx))
+			used += txGas
+			if totalBytes > maxBytes || (maxGas >= 0 && used > maxGas) {
+				return selected
+			}
+			selected = append(selected, tx)
+		}
+	}
+	return selected
+}
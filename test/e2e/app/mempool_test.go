@@ -0,0 +1,57 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempool_SelectOrdersByLane(t *testing.T) {
+	m := NewMempool(laneClassifier(&Config{MempoolLaneLowPrefixes: []string{"low"}}))
+
+	lowTx := []byte("low=1")
+	defaultTx := []byte("foo=bar")
+	highTx := []byte("extensionSum=42|validator1")
+
+	// Insert in low-to-high order; Select must still drain high before
+	// default before low, regardless of insertion order.
+	m.Insert(lowTx)
+	m.Insert(defaultTx)
+	m.Insert(highTx)
+
+	selected := m.Select(1<<20, -1)
+	require.Equal(t, [][]byte{highTx, defaultTx, lowTx}, selected)
+}
+
+func TestMempool_SelectRespectsMaxBytes(t *testing.T) {
+	m := NewMempool(nil)
+	tx1 := []byte("a=1")
+	tx2 := []byte("b=2")
+	m.Insert(tx1)
+	m.Insert(tx2)
+
+	selected := m.Select(int64(len(tx1)), -1)
+	require.Equal(t, [][]byte{tx1}, selected)
+}
+
+func TestMempool_RemoveEvictsFromAnyLane(t *testing.T) {
+	m := NewMempool(nil)
+	highTx := []byte("extensionSum=1|validator1")
+	m.Insert(highTx)
+	require.Equal(t, [][]byte{highTx}, m.Select(1<<20, -1))
+
+	m.Remove(highTx)
+	require.Empty(t, m.Select(1<<20, -1))
+
+	// A removed tx can be reinserted, e.g. if resubmitted by a client.
+	m.Insert(highTx)
+	require.Equal(t, [][]byte{highTx}, m.Select(1<<20, -1))
+}
+
+func TestMempool_InsertDeduplicates(t *testing.T) {
+	m := NewMempool(nil)
+	tx := []byte("a=1")
+	m.Insert(tx)
+	m.Insert(tx)
+	require.Equal(t, [][]byte{tx}, m.Select(1<<20, -1))
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoot(t *testing.T) {
+	_, err := snapshotRoot(nil)
+	require.Error(t, err)
+
+	_, err = snapshotRoot([]byte(`{}`))
+	require.Error(t, err)
+
+	chunks := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	root, _ := snapshotChunkProofs(chunks)
+	metadata, err := marshalSnapshotMetadata(root)
+	require.NoError(t, err)
+
+	gotRoot, err := snapshotRoot(metadata)
+	require.NoError(t, err)
+	require.Equal(t, root, gotRoot)
+}
+
+func TestChunkEnvelopeRoundTrip(t *testing.T) {
+	chunks := [][]byte{[]byte("chunk-0"), []byte("chunk-1"), []byte("chunk-2")}
+	root, proofs := snapshotChunkProofs(chunks)
+
+	for i, chunk := range chunks {
+		envelope, err := marshalChunkEnvelope(chunk, proofs[i])
+		require.NoError(t, err)
+
+		gotChunk, gotProof, err := unmarshalChunkEnvelope(envelope)
+		require.NoError(t, err)
+		require.Equal(t, chunk, gotChunk)
+		require.NoError(t, gotProof.Verify(root, gotChunk))
+	}
+}
+
+func TestChunkEnvelopeRejectsTamperedChunk(t *testing.T) {
+	chunks := [][]byte{[]byte("chunk-0"), []byte("chunk-1")}
+	root, proofs := snapshotChunkProofs(chunks)
+
+	envelope, err := marshalChunkEnvelope([]byte("tampered"), proofs[0])
+	require.NoError(t, err)
+
+	chunk, proof, err := unmarshalChunkEnvelope(envelope)
+	require.NoError(t, err)
+	require.Error(t, proof.Verify(root, chunk))
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func newRestoreTestApp(t *testing.T) *Application {
+	t.Helper()
+	return &Application{
+		logger: log.NewNopLogger(),
+		cfg:    &Config{Dir: t.TempDir()},
+	}
+}
+
+func snapshotWithRoot(height uint64, chunks uint32, root []byte) *abci.Snapshot {
+	metadata, err := marshalSnapshotMetadata(root)
+	if err != nil {
+		panic(err)
+	}
+	return &abci.Snapshot{Height: height, Format: 1, Chunks: chunks, Metadata: metadata}
+}
+
+// TestRestoreDir_ScopedBySnapshotIdentity verifies that two different
+// snapshots (here, same height but different chunk roots, as a re-exported
+// snapshot would produce) get distinct restore directories.
+func TestRestoreDir_ScopedBySnapshotIdentity(t *testing.T) {
+	app := newRestoreTestApp(t)
+
+	app.restoreSnapshot = snapshotWithRoot(100, 2, []byte("root-a"))
+	dirA := app.restoreDir()
+
+	app.restoreSnapshot = snapshotWithRoot(100, 2, []byte("root-b"))
+	dirB := app.restoreDir()
+
+	require.NotEqual(t, dirA, dirB)
+}
+
+// TestOfferSnapshot_DoesNotReuseChunksFromADifferentSnapshot verifies that
+// leftover chunk files from an abandoned restore are only resumed if they
+// belong to the snapshot currently being offered. A chunk file written for
+// one snapshot identity must not be picked up when OfferSnapshot is later
+// called for a different snapshot (even at the same height), since it was
+// never verified against that snapshot's chunk root.
+func TestOfferSnapshot_DoesNotReuseChunksFromADifferentSnapshot(t *testing.T) {
+	app := newRestoreTestApp(t)
+
+	// Simulate a chunk that was verified and persisted during a previous,
+	// abandoned restore of snapshot A.
+	app.restoreSnapshot = snapshotWithRoot(100, 2, []byte("root-a"))
+	require.NoError(t, os.MkdirAll(app.restoreDir(), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.restoreDir(), "0.chunk"), []byte("stale chunk"), 0o644))
+	app.restoreSnapshot = nil
+
+	// A new restore for a different snapshot at the same height (e.g. the
+	// node re-exported a new snapshot before the old restore completed).
+	app.OfferSnapshot(abci.RequestOfferSnapshot{Snapshot: snapshotWithRoot(100, 2, []byte("root-b"))})
+
+	require.Nil(t, app.restoreChunks[0], "chunk from a different snapshot's restore must not be reused")
+}
+
+// TestOfferSnapshot_ResumesChunksFromTheSameSnapshot verifies the intended
+// resume path still works: chunks persisted for the exact snapshot identity
+// being offered again are loaded back into restoreChunks.
+func TestOfferSnapshot_ResumesChunksFromTheSameSnapshot(t *testing.T) {
+	app := newRestoreTestApp(t)
+	snapshot := snapshotWithRoot(100, 2, []byte("root-a"))
+
+	app.restoreSnapshot = snapshot
+	require.NoError(t, os.MkdirAll(app.restoreDir(), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.restoreDir(), "0.chunk"), []byte("chunk-0"), 0o644))
+	app.restoreSnapshot = nil
+
+	app.OfferSnapshot(abci.RequestOfferSnapshot{Snapshot: snapshot})
+
+	require.Equal(t, []byte("chunk-0"), app.restoreChunks[0])
+}
+
+// TestApplySnapshotChunk_RejectedChunkAllowsRetryOfferSnapshot verifies that
+// a chunk failing Merkle verification clears the in-progress restore instead
+// of leaving it dangling. Per the ABCI statesync protocol, the syncer calls
+// OfferSnapshot again (with the same or a different candidate) after either
+// REJECT_SNAPSHOT or RETRY_SNAPSHOT; if restoreSnapshot were left set, that
+// call would panic and permanently wedge the node on the first bad chunk.
+func TestApplySnapshotChunk_RejectedChunkAllowsRetryOfferSnapshot(t *testing.T) {
+	app := newRestoreTestApp(t)
+	root, proofs := snapshotChunkProofs([][]byte{[]byte("good-chunk")})
+	snapshot := snapshotWithRoot(100, 1, root)
+
+	app.OfferSnapshot(abci.RequestOfferSnapshot{Snapshot: snapshot})
+
+	envelope, err := marshalChunkEnvelope([]byte("tampered-chunk"), proofs[0])
+	require.NoError(t, err)
+	resp := app.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{Index: 0, Chunk: envelope})
+	require.Equal(t, abci.ResponseApplySnapshotChunk_RETRY_SNAPSHOT, resp.Result)
+	require.Nil(t, app.restoreSnapshot, "failed chunk verification must clear the in-progress restore")
+
+	// The syncer retries with OfferSnapshot; this must not panic.
+	require.NotPanics(t, func() {
+		app.OfferSnapshot(abci.RequestOfferSnapshot{Snapshot: snapshot})
+	})
+	require.NotNil(t, app.restoreSnapshot)
+}
+
+// TestApplySnapshotChunk_ResumedCompleteRestoreStillAcceptsRemainingIndices
+// reproduces a crash scenario: a previous run of the app already verified
+// and persisted every chunk of a two-chunk snapshot to disk, but crashed
+// before clearing the restore. On restart, OfferSnapshot's resume logic
+// fills restoreChunks completely before a single ApplySnapshotChunk call
+// happens this run -- but the real syncer doesn't know that, and still
+// submits every index over the network based on its own bookkeeping. The
+// restore must stay in progress (restoreSnapshot non-nil) until every index
+// has actually been submitted this run, even though state.Import already
+// ran on the very first submitted index; otherwise the next submitted index
+// hits the "No restore in progress" panic.
+func TestApplySnapshotChunk_ResumedCompleteRestoreStillAcceptsRemainingIndices(t *testing.T) {
+	app := newRestoreTestApp(t)
+	state, err := NewState(t.TempDir(), 1)
+	require.NoError(t, err)
+	app.state = state
+
+	root, proofs := snapshotChunkProofs([][]byte{[]byte("chunk-0"), []byte("chunk-1")})
+	snapshot := snapshotWithRoot(100, 2, root)
+
+	// Simulate a previous run that verified and persisted both chunks to
+	// disk before crashing.
+	app.restoreSnapshot = snapshot
+	require.NoError(t, os.MkdirAll(app.restoreDir(), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(app.restoreDir(), "0.chunk"), []byte("chunk-0"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(app.restoreDir(), "1.chunk"), []byte("chunk-1"), 0o644))
+	app.restoreSnapshot = nil
+
+	app.OfferSnapshot(abci.RequestOfferSnapshot{Snapshot: snapshot})
+
+	envelope0, err := marshalChunkEnvelope([]byte("chunk-0"), proofs[0])
+	require.NoError(t, err)
+	resp := app.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{Index: 0, Chunk: envelope0})
+	require.Equal(t, abci.ResponseApplySnapshotChunk_ACCEPT, resp.Result)
+	require.NotNil(t, app.restoreSnapshot,
+		"restore must stay in progress until every index is submitted this run, even though the resumed data already let state.Import run")
+
+	envelope1, err := marshalChunkEnvelope([]byte("chunk-1"), proofs[1])
+	require.NoError(t, err)
+	require.NotPanics(t, func() {
+		resp = app.ApplySnapshotChunk(abci.RequestApplySnapshotChunk{Index: 1, Chunk: envelope1})
+	})
+	require.Equal(t, abci.ResponseApplySnapshotChunk_ACCEPT, resp.Result)
+	require.Nil(t, app.restoreSnapshot, "restore is done once every index has been submitted this run")
+}
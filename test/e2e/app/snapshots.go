@@ -0,0 +1,277 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/merkle"
+)
+
+// snapshotChunkSize is the target size of a single snapshot chunk. Create
+// splits the exported state into chunks of at most this size.
+const snapshotChunkSize = 1 << 20 // 1MB
+
+// maxSnapshotCount is the number of most recent snapshots Commit keeps on
+// disk; Prune removes any older ones.
+const maxSnapshotCount = 3
+
+// snapshotFormat is the only snapshot format this application produces.
+const snapshotFormat uint32 = 1
+
+// snapshotInfo is the on-disk sidecar persisted alongside a snapshot's
+// chunks, since List needs to reconstruct an abci.Snapshot (including its
+// Hash and chunk-root Metadata) without re-exporting state.
+type snapshotInfo struct {
+	Height uint64 `json:"height"`
+	Format uint32 `json:"format"`
+	Chunks uint32 `json:"chunks"`
+	Hash   []byte `json:"hash"`
+}
+
+// SnapshotStore persists state sync snapshots to disk, one directory per
+// height/format pair holding one file per chunk plus a snapshotInfo sidecar.
+// It is safe for concurrent use.
+type SnapshotStore struct {
+	mtx sync.Mutex
+	dir string
+}
+
+// NewSnapshotStore creates a SnapshotStore rooted at dir, creating dir if it
+// does not already exist.
+func NewSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return &SnapshotStore{dir: dir}, nil
+}
+
+// snapshotDir returns the directory holding a height/format pair's chunks
+// and sidecar metadata.
+func (s *SnapshotStore) snapshotDir(height uint64, format uint32) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%v-%v", height, format))
+}
+
+// chunkPath returns the on-disk path of a single chunk file.
+func (s *SnapshotStore) chunkPath(height uint64, format, chunk uint32) string {
+	return filepath.Join(s.snapshotDir(height, format), fmt.Sprintf("%v.chunk", chunk))
+}
+
+// infoPath returns the on-disk path of a snapshot's sidecar metadata.
+func (s *SnapshotStore) infoPath(height uint64, format uint32) string {
+	return filepath.Join(s.snapshotDir(height, format), "info.json")
+}
+
+// Create exports state at its last committed height, splits it into chunks
+// of at most snapshotChunkSize, and persists the chunks to disk together
+// with a Merkle root over the chunk hashes (stored in the returned
+// snapshot's Metadata) so that LoadChunkWithProof can later hand out
+// incremental inclusion proofs as each chunk is requested.
+func (s *SnapshotStore) Create(state *State) (*abci.Snapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	bz, err := state.Export()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export state: %w", err)
+	}
+	chunks := chunkBytes(bz, snapshotChunkSize)
+
+	dir := s.snapshotDir(state.Height, snapshotFormat)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	for i, chunk := range chunks {
+		if err := os.WriteFile(s.chunkPath(state.Height, snapshotFormat, uint32(i)), chunk, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write snapshot chunk %d: %w", i, err)
+		}
+	}
+
+	root, _ := snapshotChunkProofs(chunks)
+	metadata, err := marshalSnapshotMetadata(root)
+	if err != nil {
+		return nil, err
+	}
+
+	info := snapshotInfo{
+		Height: state.Height,
+		Format: snapshotFormat,
+		Chunks: uint32(len(chunks)),
+		Hash:   state.Hash,
+	}
+	infoBz, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot info: %w", err)
+	}
+	if err := os.WriteFile(s.infoPath(state.Height, snapshotFormat), infoBz, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot info: %w", err)
+	}
+
+	return &abci.Snapshot{
+		Height:   info.Height,
+		Format:   info.Format,
+		Chunks:   info.Chunks,
+		Hash:     info.Hash,
+		Metadata: metadata,
+	}, nil
+}
+
+// List returns all snapshots known to the store, most recent (highest
+// height) first.
+func (s *SnapshotStore) List() ([]*abci.Snapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot directory: %w", err)
+	}
+	var snapshots []*abci.Snapshot
+	for _, entry := range entries {
+		var height uint64
+		var format uint32
+		if _, err := fmt.Sscanf(entry.Name(), "%d-%d", &height, &format); err != nil {
+			continue
+		}
+		snapshot, err := s.load(height, format)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Height > snapshots[j].Height
+	})
+	return snapshots, nil
+}
+
+// load reconstructs a height/format pair's abci.Snapshot from its sidecar
+// info file and chunk root.
+func (s *SnapshotStore) load(height uint64, format uint32) (*abci.Snapshot, error) {
+	infoBz, err := os.ReadFile(s.infoPath(height, format))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot info: %w", err)
+	}
+	var info snapshotInfo
+	if err := json.Unmarshal(infoBz, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot info: %w", err)
+	}
+	_, root, err := s.loadChunks(height, format, info.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := marshalSnapshotMetadata(root)
+	if err != nil {
+		return nil, err
+	}
+	return &abci.Snapshot{
+		Height:   info.Height,
+		Format:   info.Format,
+		Chunks:   info.Chunks,
+		Hash:     info.Hash,
+		Metadata: metadata,
+	}, nil
+}
+
+// loadChunks reads all of a snapshot's chunks off disk and recomputes their
+// Merkle root.
+func (s *SnapshotStore) loadChunks(height uint64, format, count uint32) (chunks [][]byte, root []byte, err error) {
+	chunks = make([][]byte, count)
+	for i := uint32(0); i < count; i++ {
+		chunk, err := os.ReadFile(s.chunkPath(height, format, i))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read snapshot chunk %d: %w", i, err)
+		}
+		chunks[i] = chunk
+	}
+	root, _ = snapshotChunkProofs(chunks)
+	return chunks, root, nil
+}
+
+// LoadChunk returns a single chunk's raw bytes, without a proof.
+func (s *SnapshotStore) LoadChunk(height uint64, format, chunk uint32) ([]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	bz, err := os.ReadFile(s.chunkPath(height, format, chunk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot chunk %d: %w", chunk, err)
+	}
+	return bz, nil
+}
+
+// LoadChunkWithProof returns a single chunk's raw bytes together with a
+// Merkle proof of its inclusion in the chunk root published in the
+// snapshot's Metadata, so LoadSnapshotChunk's caller can verify the chunk
+// incrementally as it arrives instead of only after the whole snapshot has
+// been downloaded.
+func (s *SnapshotStore) LoadChunkWithProof(height uint64, format, chunk uint32) ([]byte, *merkle.Proof, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	info, err := s.loadInfo(height, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	if chunk >= info.Chunks {
+		return nil, nil, fmt.Errorf("chunk index %d out of range (snapshot has %d chunks)", chunk, info.Chunks)
+	}
+	chunks, _, err := s.loadChunks(height, format, info.Chunks)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, proofs := snapshotChunkProofs(chunks)
+	return chunks[chunk], proofs[chunk], nil
+}
+
+// loadInfo reads a height/format pair's sidecar info file.
+func (s *SnapshotStore) loadInfo(height uint64, format uint32) (snapshotInfo, error) {
+	infoBz, err := os.ReadFile(s.infoPath(height, format))
+	if err != nil {
+		return snapshotInfo{}, fmt.Errorf("failed to read snapshot info: %w", err)
+	}
+	var info snapshotInfo
+	if err := json.Unmarshal(infoBz, &info); err != nil {
+		return snapshotInfo{}, fmt.Errorf("failed to parse snapshot info: %w", err)
+	}
+	return info, nil
+}
+
+// Prune removes all but the retain most recent snapshots.
+func (s *SnapshotStore) Prune(retain int) error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= retain {
+		return nil
+	}
+	for _, snapshot := range snapshots[retain:] {
+		if err := os.RemoveAll(s.snapshotDir(snapshot.Height, snapshot.Format)); err != nil {
+			return fmt.Errorf("failed to prune snapshot at height %d: %w", snapshot.Height, err)
+		}
+	}
+	return nil
+}
+
+// chunkBytes splits bz into chunks of at most size bytes each. An empty bz
+// still produces one (empty) chunk, so a zero-byte state export still
+// yields a valid, single-chunk snapshot.
+func chunkBytes(bz []byte, size int) [][]byte {
+	if len(bz) == 0 {
+		return [][]byte{{}}
+	}
+	chunks := make([][]byte, 0, (len(bz)+size-1)/size)
+	for i := 0; i < len(bz); i += size {
+		end := i + size
+		if end > len(bz) {
+			end = len(bz)
+		}
+		chunks = append(chunks, bz[i:end])
+	}
+	return chunks
+}
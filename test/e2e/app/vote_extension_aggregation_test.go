@@ -0,0 +1,128 @@
+package app
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// encodeExt encodes a vote extension value the same way ExtendVote does, so
+// aggregateVoteExtensions' parseVoteExtension call accepts it.
+func encodeExt(num int64) []byte {
+	ext := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(ext, num)
+	return ext[:n]
+}
+
+// extendedVote builds an ExtendedVoteInfo for a validator identified by addr
+// (so tests can construct duplicate-address votes without real pubkeys).
+func extendedVote(addr []byte, power int64, signed bool, extValue int64) abci.ExtendedVoteInfo {
+	var ext []byte
+	if signed {
+		ext = encodeExt(extValue)
+	}
+	return abci.ExtendedVoteInfo{
+		Validator: abci.Validator{
+			Address: addr,
+			Power:   power,
+		},
+		SignedLastBlock: signed,
+		VoteExtension:   ext,
+	}
+}
+
+// asCommitInfo strips the extension data from an ExtendedCommitInfo, yielding
+// the plain CommitInfo that ProcessProposal receives as ProposedLastCommit
+// and hands to quorumMet.
+func asCommitInfo(commit abci.ExtendedCommitInfo) abci.CommitInfo {
+	votes := make([]abci.VoteInfo, len(commit.Votes))
+	for i, v := range commit.Votes {
+		votes[i] = abci.VoteInfo{
+			Validator:       v.Validator,
+			SignedLastBlock: v.SignedLastBlock,
+		}
+	}
+	return abci.CommitInfo{Votes: votes}
+}
+
+func TestAggregateVoteExtensions_QuorumBoundary(t *testing.T) {
+	logger := log.NewNopLogger()
+	const quorumFraction = 2.0 / 3.0
+
+	// Exactly at the threshold (2/3 of total power signed) must NOT meet
+	// quorum: aggregateVoteExtensions requires signedPower to exceed the
+	// fraction, not merely reach it.
+	atThreshold := abci.ExtendedCommitInfo{
+		Votes: []abci.ExtendedVoteInfo{
+			extendedVote([]byte("validator-1"), 2, true, 10),
+			extendedVote([]byte("validator-2"), 1, false, 0),
+		},
+	}
+	sum, signers := aggregateVoteExtensions(logger, atThreshold, quorumFraction)
+	require.Nil(t, signers)
+	require.Zero(t, sum)
+
+	// One more unit of signed power tips it above the threshold.
+	aboveThreshold := abci.ExtendedCommitInfo{
+		Votes: []abci.ExtendedVoteInfo{
+			extendedVote([]byte("validator-1"), 2, true, 10),
+			extendedVote([]byte("validator-2"), 1, true, 5),
+		},
+	}
+	sum, signers = aggregateVoteExtensions(logger, aboveThreshold, quorumFraction)
+	require.Len(t, signers, 2)
+	require.EqualValues(t, 15, sum)
+}
+
+// TestAggregateVoteExtensions_DuplicateValidatorVotes verifies that a second
+// vote from the same validator address is ignored rather than counted twice,
+// keeping the aggregate deterministic regardless of how many times a
+// validator's vote appears in the commit.
+func TestAggregateVoteExtensions_DuplicateValidatorVotes(t *testing.T) {
+	logger := log.NewNopLogger()
+	commit := abci.ExtendedCommitInfo{
+		Votes: []abci.ExtendedVoteInfo{
+			extendedVote([]byte("validator-1"), 10, true, 7),
+			extendedVote([]byte("validator-1"), 10, true, 999),
+		},
+	}
+
+	sum, signers := aggregateVoteExtensions(logger, commit, 0)
+	require.Equal(t, []string{string(crypto.Address([]byte("validator-1")).String())}, signers)
+	require.EqualValues(t, 7, sum, "second vote from the same validator must not be double-counted")
+}
+
+// TestAggregateVoteExtensionsAndQuorumMet_Consistent verifies that
+// quorumMet, re-derived from the plain (non-extended) commit info
+// ProcessProposal sees, agrees with aggregateVoteExtensions on whether
+// quorum was met for the same underlying commit.
+func TestAggregateVoteExtensionsAndQuorumMet_Consistent(t *testing.T) {
+	logger := log.NewNopLogger()
+	const quorumFraction = 2.0 / 3.0
+
+	cases := []abci.ExtendedCommitInfo{
+		{
+			Votes: []abci.ExtendedVoteInfo{
+				extendedVote([]byte("validator-1"), 2, true, 10),
+				extendedVote([]byte("validator-2"), 1, false, 0),
+			},
+		},
+		{
+			Votes: []abci.ExtendedVoteInfo{
+				extendedVote([]byte("validator-1"), 2, true, 10),
+				extendedVote([]byte("validator-2"), 1, true, 5),
+			},
+		},
+	}
+
+	for _, commit := range cases {
+		_, signers := aggregateVoteExtensions(logger, commit, quorumFraction)
+		met := quorumMet(asCommitInfo(commit), signers, quorumFraction)
+		require.Equal(t, signers != nil, met)
+	}
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func newEnableHeightTestApp(enableHeight int64, panicOnViolation bool) *Application {
+	return &Application{
+		logger: log.NewNopLogger(),
+		cfg: &Config{
+			VoteExtensionsEnableHeight:      enableHeight,
+			VoteExtensionsEnableHeightPanic: panicOnViolation,
+		},
+	}
+}
+
+func TestVerifyVoteExtension_RejectsBelowEnableHeight(t *testing.T) {
+	app := newEnableHeightTestApp(100, false)
+
+	resp := app.VerifyVoteExtension(abci.RequestVerifyVoteExtension{Height: 99})
+	require.Equal(t, abci.ResponseVerifyVoteExtension_REJECT, resp.Status)
+
+	// Height 0 is always rejected, even with VoteExtensionsEnableHeight
+	// disabled (its zero value).
+	zeroHeightApp := newEnableHeightTestApp(0, false)
+	resp = zeroHeightApp.VerifyVoteExtension(abci.RequestVerifyVoteExtension{Height: 0})
+	require.Equal(t, abci.ResponseVerifyVoteExtension_REJECT, resp.Status)
+}
+
+func TestVerifyVoteExtension_AcceptsAtOrAboveEnableHeight(t *testing.T) {
+	app := newEnableHeightTestApp(100, false)
+
+	resp := app.VerifyVoteExtension(abci.RequestVerifyVoteExtension{Height: 100})
+	require.Equal(t, abci.ResponseVerifyVoteExtension_ACCEPT, resp.Status)
+}
+
+func TestVerifyVoteExtension_PanicsBelowEnableHeightWhenConfigured(t *testing.T) {
+	app := newEnableHeightTestApp(100, true)
+
+	require.Panics(t, func() {
+		app.VerifyVoteExtension(abci.RequestVerifyVoteExtension{Height: 99})
+	})
+}
+
+func TestExtendVote_RejectsBelowEnableHeight(t *testing.T) {
+	app := newEnableHeightTestApp(100, false)
+
+	resp := app.ExtendVote(abci.RequestExtendVote{Height: 99})
+	require.Empty(t, resp.VoteExtension)
+}
+
+func TestExtendVote_PanicsBelowEnableHeightWhenConfigured(t *testing.T) {
+	app := newEnableHeightTestApp(100, true)
+
+	require.Panics(t, func() {
+		app.ExtendVote(abci.RequestExtendVote{Height: 99})
+	})
+}
+
+// TestExtendVoteAndVerifyVoteExtension_ConsistentBelowEnableHeight verifies
+// that, for a given config, ExtendVote and VerifyVoteExtension agree on
+// whether a call below VoteExtensionsEnableHeight panics or fails
+// gracefully -- they must not diverge, or a node would crash on its own
+// ExtendVote call while its peers merely reject the same condition in
+// VerifyVoteExtension (or vice versa).
+func TestExtendVoteAndVerifyVoteExtension_ConsistentBelowEnableHeight(t *testing.T) {
+	for _, panicOnViolation := range []bool{false, true} {
+		app := newEnableHeightTestApp(100, panicOnViolation)
+
+		extendPanicked := panics(func() { app.ExtendVote(abci.RequestExtendVote{Height: 99}) })
+		verifyPanicked := panics(func() { app.VerifyVoteExtension(abci.RequestVerifyVoteExtension{Height: 99}) })
+		require.Equal(t, panicOnViolation, extendPanicked)
+		require.Equal(t, extendPanicked, verifyPanicked)
+	}
+}
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}